@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -12,88 +13,145 @@ import (
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/google/generative-ai-go/genai"
+	"github.com/yesaswi/synapscribe-backend/internal/events"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+	"github.com/yesaswi/synapscribe-backend/internal/jobs"
 	"google.golang.org/api/option"
 )
 
-// StorageObjectData contains metadata of the Cloud Storage object.
-type StorageObjectData struct {
-	Bucket         string    `json:"bucket,omitempty"`
-	Name           string    `json:"name,omitempty"`
-	Metageneration int64     `json:"metageneration,string,omitempty"`
-	TimeCreated    time.Time `json:"timeCreated,omitempty"`
-	Updated        time.Time `json:"updated,omitempty"`
+// transcriptURLTTL bounds how long the signed URL handed back through the
+// job doc stays valid.
+const transcriptURLTTL = 7 * 24 * time.Hour
+
+// audioFileType is the events.MediaUploaded.FileType this function acts
+// on; everything else (images, videos) is ignored so other subscribers
+// can own those without AudioTranscription filtering on their behalf.
+const audioFileType = "audio"
+
+// pubsubMessage mirrors the envelope the Pub/Sub emulator and production
+// trigger wrap a published message in.
+type pubsubMessage struct {
+	Data []byte `json:"data"`
 }
 
-func init() {
-	functions.CloudEvent("AudioTranscription", AudioTranscription)
+// messagePublishedData is the CloudEvent payload Pub/Sub triggers deliver.
+type messagePublishedData struct {
+	Message pubsubMessage `json:"message"`
 }
 
-func logJSON(message string, severity string) {
-	logEntry := struct {
-		Message  string `json:"message"`
-		Severity string `json:"severity"`
-	}{
-		Message:  message,
-		Severity: severity,
-	}
-	jsonLog, _ := json.Marshal(logEntry)
-	fmt.Println(string(jsonLog))
+func init() {
+	functions.CloudEvent("AudioTranscription", AudioTranscription)
 }
 
+// AudioTranscription runs on media.uploaded events, filtering out
+// everything but audio so it isn't triggered by every object written to
+// the bucket (including its own transcription-*.txt outputs).
 func AudioTranscription(ctx context.Context, e event.Event) error {
-	logJSON(fmt.Sprintf("Event ID: %s", e.ID()), "INFO")
-	logJSON(fmt.Sprintf("Event Type: %s", e.Type()), "INFO")
+	httpx.LogJSON(fmt.Sprintf("Event ID: %s", e.ID()), httpx.SeverityInfo)
+	httpx.LogJSON(fmt.Sprintf("Event Type: %s", e.Type()), httpx.SeverityInfo)
 
-	var data StorageObjectData
-	if err := e.DataAs(&data); err != nil {
-		logJSON(fmt.Sprintf("event.DataAs: %v", err), "ERROR")
+	var msg messagePublishedData
+	if err := e.DataAs(&msg); err != nil {
+		httpx.LogJSON(fmt.Sprintf("event.DataAs: %v", err), httpx.SeverityError)
 		return fmt.Errorf("event.DataAs: %v", err)
 	}
 
-	logJSON(fmt.Sprintf("Bucket: %s", data.Bucket), "INFO")
-	logJSON(fmt.Sprintf("File: %s", data.Name), "INFO")
-	logJSON(fmt.Sprintf("Metageneration: %d", data.Metageneration), "INFO")
-	logJSON(fmt.Sprintf("Created: %s", data.TimeCreated), "INFO")
-	logJSON(fmt.Sprintf("Updated: %s", data.Updated), "INFO")
+	var uploaded events.MediaUploaded
+	if err := json.Unmarshal(msg.Message.Data, &uploaded); err != nil {
+		httpx.LogJSON(fmt.Sprintf("failed to decode media.uploaded message: %v", err), httpx.SeverityError)
+		return fmt.Errorf("failed to decode media.uploaded message: %v", err)
+	}
+
+	if uploaded.FileType != audioFileType {
+		httpx.LogJSON(fmt.Sprintf("Ignoring non-audio upload: %s", uploaded.Object), httpx.SeverityInfo)
+		return nil
+	}
+
+	httpx.LogJSON(fmt.Sprintf("Bucket: %s", uploaded.Bucket), httpx.SeverityInfo)
+	httpx.LogJSON(fmt.Sprintf("File: %s", uploaded.Object), httpx.SeverityInfo)
+
+	gcsURL := fmt.Sprintf("gs://%s/%s", uploaded.Bucket, uploaded.Object)
+
+	if err := jobs.SetStatus(ctx, uploaded.Object, jobs.StatusTranscribing); err != nil {
+		httpx.LogJSON(fmt.Sprintf("Failed to update job status: %v", err), httpx.SeverityError)
+	}
 
-	gcsURL := fmt.Sprintf("gs://%s/%s", data.Bucket, data.Name)
-	
 	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("GEMINI_API_KEY")))
 	if err != nil {
-		logJSON(fmt.Sprintf("Failed to create Gemini client: %v", err), "ERROR")
+		httpx.LogJSON(fmt.Sprintf("Failed to create Gemini client: %v", err), httpx.SeverityError)
+		jobs.SetFailed(ctx, uploaded.Object, err)
 		return fmt.Errorf("failed to create Gemini client: %v", err)
 	}
 	defer client.Close()
 
 	transcription, err := transcribeAudio(ctx, client, gcsURL)
 	if err != nil {
-		logJSON(fmt.Sprintf("Failed to transcribe audio: %v", err), "ERROR")
+		httpx.LogJSON(fmt.Sprintf("Failed to transcribe audio: %v", err), httpx.SeverityError)
+		jobs.SetFailed(ctx, uploaded.Object, err)
 		return fmt.Errorf("failed to transcribe audio: %v", err)
 	}
 
 	gcsClient, err := storage.NewClient(ctx)
 	if err != nil {
-		logJSON(fmt.Sprintf("Failed to create GCS client: %v", err), "ERROR")
-		return fmt.Errorf("failed to create GCS client: %v", err)	
+		httpx.LogJSON(fmt.Sprintf("Failed to create GCS client: %v", err), httpx.SeverityError)
+		jobs.SetFailed(ctx, uploaded.Object, err)
+		return fmt.Errorf("failed to create GCS client: %v", err)
 	}
 	defer gcsClient.Close()
 
 	// Save the response to a file in a Cloud Storage bucket
 	bucket := os.Getenv("TRANSCRIPTION_BUCKET")
-	object := fmt.Sprintf("transcription-%s.txt", data.Name)
+	object := fmt.Sprintf("transcription-%s.txt", uploaded.Object)
 	obj := gcsClient.Bucket(bucket).Object(object)
 	writer := obj.NewWriter(ctx)
 	defer writer.Close()
 	if _, err := writer.Write([]byte(transcription)); err != nil {
-		logJSON(fmt.Sprintf("Failed to write transcription to GCS: %v", err), "ERROR")
+		httpx.LogJSON(fmt.Sprintf("Failed to write transcription to GCS: %v", err), httpx.SeverityError)
+		jobs.SetFailed(ctx, uploaded.Object, err)
 		return fmt.Errorf("failed to write transcription to GCS: %v", err)
 	}
+	if err := writer.Close(); err != nil {
+		httpx.LogJSON(fmt.Sprintf("Failed to finalize transcription in GCS: %v", err), httpx.SeverityError)
+		jobs.SetFailed(ctx, uploaded.Object, err)
+		return fmt.Errorf("failed to finalize transcription in GCS: %v", err)
+	}
+
+	transcriptURL, err := gcsClient.Bucket(bucket).SignedURL(object, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(transcriptURLTTL),
+	})
+	if err != nil {
+		httpx.LogJSON(fmt.Sprintf("Failed to sign transcript URL: %v", err), httpx.SeverityError)
+		jobs.SetFailed(ctx, uploaded.Object, err)
+		return fmt.Errorf("failed to sign transcript URL: %v", err)
+	}
 
-	logJSON("Transcription completed successfully", "INFO")
+	if err := jobs.SetDone(ctx, uploaded.Object, transcriptURL); err != nil {
+		httpx.LogJSON(fmt.Sprintf("Failed to update job status: %v", err), httpx.SeverityError)
+	}
+
+	httpx.LogJSON("Transcription completed successfully", httpx.SeverityInfo)
 	return nil
 }
 
+// transcribeAudio transcribes the audio object at gcsURL. Files longer
+// than longAudioThreshold are split into overlapping segments and
+// stitched back together; everything else goes through Gemini in one
+// call.
 func transcribeAudio(ctx context.Context, client *genai.Client, gcsURL string) (string, error) {
+	bucket, object, err := parseGCSURL(gcsURL)
+	if err != nil {
+		httpx.LogJSON(fmt.Sprintf("Invalid GCS URL: %v", err), httpx.SeverityError)
+		return "", fmt.Errorf("invalid GCS URL: %w", err)
+	}
+
+	return transcribeLongAudio(ctx, client, bucket, object)
+}
+
+// generateTranscript runs the transcription prompt against an already
+// Gemini-uploaded file.
+func generateTranscript(ctx context.Context, client *genai.Client, fileURI string) (string, error) {
 	model := client.GenerativeModel("gemini-1.5-pro")
 	model.SetTemperature(0.4)
 	model.SafetySettings = []*genai.SafetySetting{
@@ -115,50 +173,19 @@ func transcribeAudio(ctx context.Context, client *genai.Client, gcsURL string) (
 		},
 	}
 	prompt := "Transcribe this audio file. Provide only the transcribed text without any additional formatting or speaker identification."
-	
-	// Parse the GCS URL to get bucket and object names
-	bucket, object, err := parseGCSURL(gcsURL)
-	if err != nil {
-		logJSON(fmt.Sprintf("Invalid GCS URL: %v", err), "ERROR")
-		return "", fmt.Errorf("invalid GCS URL: %w", err)
-	}
-
-	// Create a new GCS client
-	gcsClient, err := storage.NewClient(ctx, option.WithScopes(storage.ScopeReadOnly))
-	if err != nil {
-		logJSON(fmt.Sprintf("Failed to create GCS client: %v", err), "ERROR")
-		return "", fmt.Errorf("failed to create GCS client: %w", err)
-	}
-	defer gcsClient.Close()
-
-	// Get a handle to the GCS object
-	obj := gcsClient.Bucket(bucket).Object(object)
-	reader, err := obj.NewReader(ctx)
-	if err != nil {
-		logJSON(fmt.Sprintf("Failed to create reader for GCS object: %v", err), "ERROR")
-		return "", fmt.Errorf("failed to create reader for GCS object: %w", err)
-	}
-	defer reader.Close()
-
-	// Upload the file to the Gemini service
-	file, err := client.UploadFile(ctx, "", reader, nil)
-	if err != nil {
-		logJSON(fmt.Sprintf("Unable to upload file: %v", err), "ERROR")
-		return "", fmt.Errorf("unable to upload file: %w", err)
-	}
 
-	res, err := model.GenerateContent(ctx, genai.FileData{URI: file.URI}, genai.Text(prompt))
+	res, err := model.GenerateContent(ctx, genai.FileData{URI: fileURI}, genai.Text(prompt))
 	if err != nil {
-		logJSON(fmt.Sprintf("Unable to generate contents: %v", err), "ERROR")
+		httpx.LogJSON(fmt.Sprintf("Unable to generate contents: %v", err), httpx.SeverityError)
 		return "", fmt.Errorf("unable to generate contents: %w", err)
 	}
 
 	if len(res.Candidates) == 0 || len(res.Candidates[0].Content.Parts) == 0 {
-		logJSON("Empty response from model", "ERROR")
+		httpx.LogJSON("Empty response from model", httpx.SeverityError)
 		return "", fmt.Errorf("empty response from model")
 	}
 
-	logJSON("Audio transcription completed", "INFO")
+	httpx.LogJSON("Audio transcription completed", httpx.SeverityInfo)
 	return fmt.Sprintf("%v", res.Candidates[0].Content.Parts[0]), nil
 }
 
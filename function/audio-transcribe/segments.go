@@ -0,0 +1,542 @@
+package audiotranscription
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+	"github.com/yesaswi/synapscribe-backend/internal/jobs"
+	"google.golang.org/api/option"
+)
+
+const (
+	// longAudioThreshold is the duration above which a file is split into
+	// overlapping segments instead of transcribed in a single request,
+	// since gemini-1.5-pro can't reliably transcribe arbitrarily long
+	// audio in one call.
+	longAudioThreshold = 15 * time.Minute
+
+	// segmentLength is the target duration of each segment.
+	segmentLength = 10 * time.Minute
+
+	// segmentOverlap must stay >= 2s so the LCS merge below has enough
+	// anchor tokens to find the seam between adjacent segments.
+	segmentOverlap = 15 * time.Second
+
+	// maxConcurrentSegments bounds in-flight Gemini requests per file.
+	maxConcurrentSegments = 4
+
+	// overlapWordWindow is how many trailing/leading words of each
+	// segment's transcript are searched for the stitching seam.
+	overlapWordWindow = 40
+)
+
+// segmentWindow is one time-bounded slice of the source audio.
+type segmentWindow struct {
+	Index int
+	Start time.Duration
+	End   time.Duration
+}
+
+// planSegments splits a file of the given duration into overlapping
+// windows. A file at or under longAudioThreshold comes back as a single
+// window covering the whole file, so callers can use the same path for
+// both short and long audio.
+func planSegments(duration time.Duration) []segmentWindow {
+	if duration <= longAudioThreshold {
+		return []segmentWindow{{Index: 0, Start: 0, End: duration}}
+	}
+
+	var windows []segmentWindow
+	start := time.Duration(0)
+	for i := 0; start < duration; i++ {
+		end := start + segmentLength
+		if end > duration {
+			end = duration
+		}
+		windows = append(windows, segmentWindow{Index: i, Start: start, End: end})
+		if end == duration {
+			break
+		}
+		start = end - segmentOverlap
+	}
+	return windows
+}
+
+// transcribeLongAudio probes the object's duration, plans segments, and
+// transcribes them with bounded concurrency, stitching the results back
+// into a single transcript. Segment-level outcomes are persisted to the
+// job doc so a failed segment can be retried without redoing the whole
+// file.
+func transcribeLongAudio(ctx context.Context, client *genai.Client, bucket, object string) (string, error) {
+	gcsClient, err := storage.NewClient(ctx, option.WithScopes(storage.ScopeReadOnly))
+	if err != nil {
+		return "", fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	defer gcsClient.Close()
+
+	duration, err := probeDuration(ctx, gcsClient, bucket, object)
+	if err != nil {
+		return "", fmt.Errorf("failed to probe duration: %w", err)
+	}
+
+	windows := planSegments(duration)
+	if len(windows) == 1 {
+		return transcribeWholeFile(ctx, client, gcsClient, bucket, object)
+	}
+
+	httpx.LogJSON(fmt.Sprintf("Splitting %s into %d segments (%s each, %s overlap)", object, len(windows), segmentLength, segmentOverlap), httpx.SeverityInfo)
+
+	results := make([]string, len(windows))
+	errs := make([]error, len(windows))
+	sem := make(chan struct{}, maxConcurrentSegments)
+	var wg sync.WaitGroup
+
+	for _, win := range windows {
+		win := win
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			transcript, err := transcribeSegment(ctx, client, gcsClient, bucket, object, win)
+			results[win.Index] = transcript
+			errs[win.Index] = err
+
+			result := jobs.SegmentResult{Index: win.Index, Status: jobs.SegmentDone, Transcript: transcript}
+			if err != nil {
+				result.Status = jobs.SegmentFailed
+				result.Error = err.Error()
+			}
+			if setErr := jobs.SetSegmentResult(ctx, object, result); setErr != nil {
+				httpx.LogJSON(fmt.Sprintf("Failed to persist segment %d result: %v", win.Index, setErr), httpx.SeverityError)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []int
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) > 0 {
+		return "", fmt.Errorf("segments %v failed to transcribe", failed)
+	}
+
+	return stitchTranscripts(results), nil
+}
+
+// transcribeSegment extracts one time window of the source audio, uploads
+// it to the Gemini Files API, and transcribes it in isolation.
+func transcribeSegment(ctx context.Context, client *genai.Client, gcsClient *storage.Client, bucket, object string, win segmentWindow) (string, error) {
+	segmentReader, err := extractSegment(ctx, gcsClient, bucket, object, win)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract segment %d: %w", win.Index, err)
+	}
+	defer segmentReader.Close()
+
+	file, err := client.UploadFile(ctx, "", segmentReader, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload segment %d: %w", win.Index, err)
+	}
+
+	return generateTranscript(ctx, client, file.URI)
+}
+
+// transcribeWholeFile covers the single-segment case: the file fits under
+// longAudioThreshold, so it's uploaded and transcribed as-is.
+func transcribeWholeFile(ctx context.Context, client *genai.Client, gcsClient *storage.Client, bucket, object string) (string, error) {
+	obj := gcsClient.Bucket(bucket).Object(object)
+	reader, err := obj.NewReader(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create reader for GCS object: %w", err)
+	}
+	defer reader.Close()
+
+	file, err := client.UploadFile(ctx, "", reader, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to upload file: %w", err)
+	}
+
+	return generateTranscript(ctx, client, file.URI)
+}
+
+// stitchTranscripts merges adjacent segment transcripts, deduping the
+// words that both segments captured in their shared overlap window.
+func stitchTranscripts(transcripts []string) string {
+	if len(transcripts) == 0 {
+		return ""
+	}
+
+	merged := strings.Fields(transcripts[0])
+	for i := 1; i < len(transcripts); i++ {
+		merged = mergeOverlap(merged, strings.Fields(transcripts[i]))
+	}
+	return strings.Join(merged, " ")
+}
+
+// mergeOverlap finds the longest common subsequence between the tail of a
+// and the head of b within overlapWordWindow words, then drops that many
+// words from the head of b before appending it to a.
+func mergeOverlap(a, b []string) []string {
+	aStart := len(a) - overlapWordWindow
+	if aStart < 0 {
+		aStart = 0
+	}
+	aSuffix := a[aStart:]
+
+	bEnd := overlapWordWindow
+	if bEnd > len(b) {
+		bEnd = len(b)
+	}
+	bPrefix := b[:bEnd]
+
+	overlap := longestCommonSubsequenceLen(aSuffix, bPrefix)
+	return append(a, b[overlap:]...)
+}
+
+func longestCommonSubsequenceLen(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else if dp[i-1][j] > dp[i][j-1] {
+				dp[i][j] = dp[i-1][j]
+			} else {
+				dp[i][j] = dp[i][j-1]
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
+
+// --- duration probing ---
+
+// probeDuration determines an audio file's duration, parsing the
+// container header directly for WAV and falling back to an ffprobe
+// sidecar Cloud Run service for compressed formats (mp3, ogg, ...) this
+// package can't decode on its own.
+func probeDuration(ctx context.Context, gcsClient *storage.Client, bucket, object string) (time.Duration, error) {
+	obj := gcsClient.Bucket(bucket).Object(object)
+	reader, err := obj.NewRangeReader(ctx, 0, 4096)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	defer reader.Close()
+
+	header, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	if duration, ok := parseWAVDuration(header); ok {
+		return duration, nil
+	}
+
+	return probeDurationViaSidecar(ctx, fmt.Sprintf("gs://%s/%s", bucket, object))
+}
+
+// parseWAVDuration reads a RIFF/WAVE header's byte rate and data chunk
+// size to compute duration without needing ffprobe at all.
+func parseWAVDuration(header []byte) (time.Duration, bool) {
+	if len(header) < 44 || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, false
+	}
+
+	byteRate := binary.LittleEndian.Uint32(header[28:32])
+	if byteRate == 0 {
+		return 0, false
+	}
+
+	offset := 12
+	for offset+8 <= len(header) {
+		chunkID := string(header[offset : offset+4])
+		chunkSize := binary.LittleEndian.Uint32(header[offset+4 : offset+8])
+		if chunkID == "data" {
+			return time.Duration(float64(chunkSize) / float64(byteRate) * float64(time.Second)), true
+		}
+		offset += 8 + int(chunkSize)
+	}
+	return 0, false
+}
+
+type probeSidecarRequest struct {
+	GCSURL string `json:"gcsUrl"`
+}
+
+type probeSidecarResponse struct {
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// probeDurationViaSidecar calls out to a Cloud Run service that shells
+// out to ffprobe, since the transcription function itself has no ffmpeg
+// available.
+func probeDurationViaSidecar(ctx context.Context, gcsURL string) (time.Duration, error) {
+	sidecarURL := os.Getenv("AUDIO_PROBE_SIDECAR_URL")
+	if sidecarURL == "" {
+		return 0, fmt.Errorf("non-WAV duration probing requires AUDIO_PROBE_SIDECAR_URL (ffprobe sidecar)")
+	}
+
+	body, err := json.Marshal(probeSidecarRequest{GCSURL: gcsURL})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode sidecar request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sidecarURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build sidecar request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach ffprobe sidecar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("ffprobe sidecar returned status %d", resp.StatusCode)
+	}
+
+	var sidecarResp probeSidecarResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sidecarResp); err != nil {
+		return 0, fmt.Errorf("failed to decode sidecar response: %w", err)
+	}
+
+	return time.Duration(sidecarResp.DurationSeconds * float64(time.Second)), nil
+}
+
+// --- segment extraction ---
+
+// extractSegment returns a reader over just the audio for one time
+// window. WAV files are sliced in-process, aligned to whole sample
+// frames so a cut never lands mid-sample. Other formats are delegated to
+// the ffmpeg segmenter sidecar at AUDIO_SEGMENTER_SIDECAR_URL, which
+// understands their container layout.
+func extractSegment(ctx context.Context, gcsClient *storage.Client, bucket, object string, win segmentWindow) (io.ReadCloser, error) {
+	obj := gcsClient.Bucket(bucket).Object(object)
+	header, err := readHeader(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if wav, ok := parseWAVFormat(header); ok {
+		return extractWAVSegment(ctx, obj, wav, win)
+	}
+
+	return extractSegmentViaSidecar(ctx, fmt.Sprintf("gs://%s/%s", bucket, object), win)
+}
+
+func readHeader(ctx context.Context, obj *storage.ObjectHandle) ([]byte, error) {
+	reader, err := obj.NewRangeReader(ctx, 0, 4096)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+type wavFormat struct {
+	ByteRate      uint32
+	BlockAlign    uint16
+	NumChannels   uint16
+	SampleRate    uint32
+	BitsPerSample uint16
+	DataOffset    int64
+	DataSize      uint32
+}
+
+func parseWAVFormat(header []byte) (wavFormat, bool) {
+	if len(header) < 44 || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return wavFormat{}, false
+	}
+
+	var wav wavFormat
+	offset := 12
+	for offset+8 <= len(header) {
+		chunkID := string(header[offset : offset+4])
+		chunkSize := binary.LittleEndian.Uint32(header[offset+4 : offset+8])
+		chunkStart := offset + 8
+
+		switch chunkID {
+		case "fmt ":
+			if chunkStart+16 > len(header) {
+				return wavFormat{}, false
+			}
+			wav.NumChannels = binary.LittleEndian.Uint16(header[chunkStart+2 : chunkStart+4])
+			wav.SampleRate = binary.LittleEndian.Uint32(header[chunkStart+4 : chunkStart+8])
+			wav.ByteRate = binary.LittleEndian.Uint32(header[chunkStart+8 : chunkStart+12])
+			wav.BlockAlign = binary.LittleEndian.Uint16(header[chunkStart+12 : chunkStart+14])
+			wav.BitsPerSample = binary.LittleEndian.Uint16(header[chunkStart+14 : chunkStart+16])
+		case "data":
+			wav.DataOffset = int64(chunkStart)
+			wav.DataSize = chunkSize
+			return wav, wav.ByteRate != 0 && wav.BlockAlign != 0
+		}
+		offset = chunkStart + int(chunkSize)
+	}
+	return wavFormat{}, false
+}
+
+// extractWAVSegment slices the PCM data for a time window, aligning both
+// the start offset and length down to whole sample frames (BlockAlign),
+// and wraps it in a fresh, self-contained WAV header so the slice is a
+// valid file on its own.
+func extractWAVSegment(ctx context.Context, obj *storage.ObjectHandle, wav wavFormat, win segmentWindow) (io.ReadCloser, error) {
+	blockAlign := int64(wav.BlockAlign)
+
+	startByte := int64(win.Start.Seconds() * float64(wav.ByteRate))
+	startByte -= startByte % blockAlign
+
+	endByte := int64(win.End.Seconds() * float64(wav.ByteRate))
+	endByte -= endByte % blockAlign
+	if maxEnd := int64(wav.DataSize); endByte > maxEnd {
+		endByte = maxEnd - (maxEnd % blockAlign)
+	}
+
+	length := endByte - startByte
+	if length <= 0 {
+		return nil, fmt.Errorf("empty segment window %d", win.Index)
+	}
+
+	pcmReader, err := obj.NewRangeReader(ctx, wav.DataOffset+startByte, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segment %d: %w", win.Index, err)
+	}
+	defer pcmReader.Close()
+
+	pcm, err := io.ReadAll(pcmReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segment %d: %w", win.Index, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(buildWAVFile(wav, pcm))), nil
+}
+
+// buildWAVFile wraps raw PCM samples in a minimal, valid RIFF/WAVE
+// header so each segment can be uploaded to the Gemini Files API on its
+// own.
+func buildWAVFile(wav wavFormat, pcm []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&buf, binary.LittleEndian, wav.NumChannels)
+	binary.Write(&buf, binary.LittleEndian, wav.SampleRate)
+	binary.Write(&buf, binary.LittleEndian, wav.ByteRate)
+	binary.Write(&buf, binary.LittleEndian, wav.BlockAlign)
+	binary.Write(&buf, binary.LittleEndian, wav.BitsPerSample)
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+
+	return buf.Bytes()
+}
+
+type segmentSidecarRequest struct {
+	GCSURL       string  `json:"gcsUrl"`
+	StartSeconds float64 `json:"startSeconds"`
+	EndSeconds   float64 `json:"endSeconds"`
+}
+
+type segmentSidecarResponse struct {
+	SegmentGCSURL string `json:"segmentGcsUrl"`
+}
+
+// extractSegmentViaSidecar delegates cutting non-WAV formats to the
+// segmenter sidecar configured by AUDIO_SEGMENTER_SIDECAR_URL (separate
+// from the AUDIO_PROBE_SIDECAR_URL duration probe), which shells out to
+// ffmpeg to produce the slice and writes it back to GCS.
+func extractSegmentViaSidecar(ctx context.Context, gcsURL string, win segmentWindow) (io.ReadCloser, error) {
+	sidecarURL := os.Getenv("AUDIO_SEGMENTER_SIDECAR_URL")
+	if sidecarURL == "" {
+		return nil, fmt.Errorf("segmenting non-WAV audio requires AUDIO_SEGMENTER_SIDECAR_URL (ffmpeg sidecar)")
+	}
+
+	body, err := json.Marshal(segmentSidecarRequest{
+		GCSURL:       gcsURL,
+		StartSeconds: win.Start.Seconds(),
+		EndSeconds:   win.End.Seconds(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sidecar request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sidecarURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sidecar request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach segmenter sidecar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("segmenter sidecar returned status %d", resp.StatusCode)
+	}
+
+	var sidecarResp segmentSidecarResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sidecarResp); err != nil {
+		return nil, fmt.Errorf("failed to decode sidecar response: %w", err)
+	}
+
+	bucket, object, err := parseGCSURL(sidecarResp.SegmentGCSURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid segment GCS URL: %w", err)
+	}
+
+	gcsClient, err := storage.NewClient(ctx, option.WithScopes(storage.ScopeReadOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	reader, err := gcsClient.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		gcsClient.Close()
+		return nil, err
+	}
+
+	return clientClosingReader{ReadCloser: reader, client: gcsClient}, nil
+}
+
+// clientClosingReader ties a GCS client's lifetime to the reader handed
+// back to the caller, since the client can't be closed until the caller
+// is done reading from it.
+type clientClosingReader struct {
+	io.ReadCloser
+	client *storage.Client
+}
+
+func (r clientClosingReader) Close() error {
+	readErr := r.ReadCloser.Close()
+	if err := r.client.Close(); err != nil {
+		return err
+	}
+	return readErr
+}
@@ -0,0 +1,51 @@
+package jobstatus
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+	"github.com/yesaswi/synapscribe-backend/internal/jobs"
+)
+
+func init() {
+	functions.HTTP("GetJobStatus", httpx.WithCORS(httpx.WithAuth(httpx.WithJSONErrors(GetJobStatus))))
+}
+
+type JobStatusResponse struct {
+	ObjectName    string `json:"objectName"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	TranscriptURL string `json:"transcriptUrl,omitempty"`
+}
+
+// GetJobStatus lets a client poll the progress of an upload/transcription
+// job it started, scoping the lookup to the caller's uid.
+func GetJobStatus(w http.ResponseWriter, r *http.Request) {
+	uid, ok := httpx.UIDFromContext(r.Context())
+	if !ok {
+		httpx.WriteError(w, http.StatusUnauthorized, "No token provided")
+		return
+	}
+
+	objectName := r.URL.Query().Get("objectName")
+	if objectName == "" {
+		httpx.WriteError(w, http.StatusBadRequest, "objectName is required")
+		return
+	}
+
+	job, err := jobs.Get(r.Context(), uid, objectName)
+	if err != nil {
+		httpx.WriteError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobStatusResponse{
+		ObjectName:    job.ObjectName,
+		Status:        string(job.Status),
+		Error:         job.Error,
+		TranscriptURL: job.TranscriptURL,
+	})
+}
@@ -1,7 +1,7 @@
 package mediaupload
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,11 +12,19 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/events"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+	"github.com/yesaswi/synapscribe-backend/internal/jobs"
 )
 
 const (
 	bucketName = "synapscribe-media"
 	maxFileSize = 50 * 1024 * 1024 // 50MB
+
+	// uploadChunkSize is the size of each resumable chunk written to GCS.
+	// Keeping this well below maxFileSize lets large uploads survive a
+	// single failed chunk instead of restarting the whole transfer.
+	uploadChunkSize = 8 * 1024 * 1024 // 8MB
 )
 
 type MediaUploadResponse struct {
@@ -26,26 +34,28 @@ type MediaUploadResponse struct {
 	UploadedAt time.Time `json:"uploadedAt"`
 }
 
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
 func init() {
-	functions.HTTP("MediaUpload", MediaUpload)
+	functions.HTTP("MediaUpload", httpx.WithCORS(httpx.WithAuth(httpx.WithJSONErrors(MediaUpload))))
 }
 
 func MediaUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, ok := httpx.UIDFromContext(ctx)
+	if !ok {
+		httpx.WriteError(w, http.StatusUnauthorized, "No token provided")
+		return
+	}
+
 	// Parse multipart form
 	err := r.ParseMultipartForm(maxFileSize)
 	if err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "Failed to parse form")
+		httpx.WriteError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "No file uploaded")
+		httpx.WriteError(w, http.StatusBadRequest, "No file uploaded")
 		return
 	}
 	defer file.Close()
@@ -53,30 +63,81 @@ func MediaUpload(w http.ResponseWriter, r *http.Request) {
 	// Validate file type
 	fileType := getFileType(header.Filename)
 	if fileType == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "Unsupported file type")
+		httpx.WriteError(w, http.StatusBadRequest, "Unsupported file type")
+		return
+	}
+
+	// Sniff the real content type rather than trusting the extension: a
+	// client can name arbitrary bytes "track.mp3".
+	headerBytes, sniffedFile, err := sniffHeader(file)
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, "Failed to read uploaded file")
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if err := validateContentType(fileType, ext, headerBytes); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Read the whole upload into memory so it can be scanned before the GCS
+	// object ever exists. Unlike FinalizeUpload's signed-URL path, this
+	// handler mediates the stream directly and so can reject infected
+	// content before finalizing it, instead of scanning and deleting after
+	// the fact.
+	content, err := io.ReadAll(sniffedFile)
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, "Failed to read uploaded file")
+		return
+	}
+	if err := scanClean(ctx, bytes.NewReader(content)); err != nil {
+		httpx.WriteError(w, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 
 	// Upload to GCS
-	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create storage client")
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to create storage client")
 		return
 	}
 	defer client.Close()
 
 	bucket := client.Bucket(bucketName)
-	objectName := fmt.Sprintf("%s/%s", fileType, header.Filename)
+	objectName := fmt.Sprintf("%s/%d-%s", fileType, time.Now().UnixNano(), header.Filename)
+
+	if err := jobs.Create(ctx, uid, bucketName, objectName, jobs.StatusUploading); err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to create job")
+		return
+	}
+
 	obj := bucket.Object(objectName)
 	writer := obj.NewWriter(ctx)
-	
-	if _, err := io.Copy(writer, file); err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to upload file")
+	writer.ChunkSize = uploadChunkSize
+	writer.ProgressFunc = func(written int64) {
+		logUploadProgress(objectName, written, header.Size)
+	}
+
+	if _, err := io.Copy(writer, bytes.NewReader(content)); err != nil {
+		jobs.SetFailed(ctx, objectName, err)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to upload file")
 		return
 	}
 	if err := writer.Close(); err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to finalize upload")
+		jobs.SetFailed(ctx, objectName, err)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to finalize upload")
+		return
+	}
+
+	if err := events.PublishMediaUploaded(ctx, events.MediaUploaded{
+		UserID:   uid,
+		Bucket:   bucketName,
+		Object:   objectName,
+		FileType: fileType,
+		JobID:    jobs.DocID(objectName),
+	}); err != nil {
+		jobs.SetFailed(ctx, objectName, err)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to publish upload event")
 		return
 	}
 
@@ -109,11 +170,6 @@ func getFileType(filename string) string {
 	}
 }
 
-func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(ErrorResponse{
-		Code:    statusCode,
-		Message: message,
-	})
+func logUploadProgress(objectName string, written, total int64) {
+	httpx.LogJSON(fmt.Sprintf("uploading %s: %d/%d bytes", objectName, written, total), httpx.SeverityInfo)
 }
@@ -0,0 +1,208 @@
+package mediaupload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/events"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+	"github.com/yesaswi/synapscribe-backend/internal/jobs"
+)
+
+// signedURLTTL bounds how long a client has to complete a direct-to-GCS
+// upload before the signed URL expires.
+const signedURLTTL = 15 * time.Minute
+
+type UploadURLRequest struct {
+	FileName string `json:"fileName"`
+}
+
+type UploadURLResponse struct {
+	UploadURL  string    `json:"uploadUrl"`
+	ObjectName string    `json:"objectName"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type FinalizeUploadRequest struct {
+	ObjectName string `json:"objectName"`
+}
+
+func init() {
+	functions.HTTP("GetUploadURL", httpx.WithCORS(httpx.WithAuth(httpx.WithJSONErrors(GetUploadURL))))
+	functions.HTTP("FinalizeUpload", httpx.WithCORS(httpx.WithAuth(httpx.WithJSONErrors(FinalizeUpload))))
+}
+
+// GetUploadURL issues a V4 signed PUT URL so the client can upload large
+// audio/video files directly to GCS, bypassing the function's request-body
+// limits entirely.
+func GetUploadURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpx.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := httpx.UIDFromContext(ctx)
+	if !ok {
+		httpx.WriteError(w, http.StatusUnauthorized, "No token provided")
+		return
+	}
+
+	var req UploadURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	fileType := getFileType(req.FileName)
+	if fileType == "" {
+		httpx.WriteError(w, http.StatusBadRequest, "Unsupported file type")
+		return
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to create storage client")
+		return
+	}
+	defer client.Close()
+
+	objectName := fmt.Sprintf("%s/%d-%s", fileType, time.Now().UnixNano(), req.FileName)
+	expiresAt := time.Now().Add(signedURLTTL)
+	uploadURL, err := client.Bucket(bucketName).SignedURL(objectName, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodPut,
+		Expires: expiresAt,
+	})
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to generate upload URL")
+		return
+	}
+
+	if err := jobs.Create(ctx, uid, bucketName, objectName, jobs.StatusPending); err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to create job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UploadURLResponse{
+		UploadURL:  uploadURL,
+		ObjectName: objectName,
+		ExpiresAt:  expiresAt,
+	})
+}
+
+// FinalizeUpload confirms a direct-to-GCS upload actually landed before the
+// caller treats the file as available, and returns the same response shape
+// as the server-side upload path.
+func FinalizeUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpx.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := httpx.UIDFromContext(ctx)
+	if !ok {
+		httpx.WriteError(w, http.StatusUnauthorized, "No token provided")
+		return
+	}
+
+	var req FinalizeUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Scope to the caller's own job, the same way GetJobStatus does, so a
+	// client can't finalize, rescan, or trigger transcription for an object
+	// name belonging to another user.
+	if _, err := jobs.Get(ctx, uid, req.ObjectName); err != nil {
+		httpx.WriteError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to create storage client")
+		return
+	}
+	defer client.Close()
+
+	obj := client.Bucket(bucketName).Object(req.ObjectName)
+	attrs, err := obj.Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		httpx.WriteError(w, http.StatusNotFound, "Uploaded object not found")
+		return
+	}
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to verify upload")
+		return
+	}
+
+	fileType, fileName, ok := strings.Cut(req.ObjectName, "/")
+	if !ok {
+		fileName = req.ObjectName
+	}
+
+	header, err := obj.NewRangeReader(ctx, 0, sniffLen)
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to read uploaded object")
+		return
+	}
+	headerBytes, err := io.ReadAll(header)
+	header.Close()
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to read uploaded object")
+		return
+	}
+	if err := validateContentType(fileType, strings.ToLower(filepath.Ext(fileName)), headerBytes); err != nil {
+		obj.Delete(ctx)
+		httpx.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := jobs.SetStatus(ctx, req.ObjectName, jobs.StatusUploading); err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to update job")
+		return
+	}
+
+	// The client already PUT directly to GCS, so the object exists before
+	// this handler ever sees it — scanObject's post-hoc scan-and-delete is
+	// the best available check here (see its doc comment).
+	if err := scanObject(ctx, obj, req.ObjectName); err != nil {
+		httpx.WriteError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := events.PublishMediaUploaded(ctx, events.MediaUploaded{
+		UserID:   uid,
+		Bucket:   bucketName,
+		Object:   req.ObjectName,
+		FileType: fileType,
+		JobID:    jobs.DocID(req.ObjectName),
+	}); err != nil {
+		jobs.SetFailed(ctx, req.ObjectName, err)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to publish upload event")
+		return
+	}
+
+	response := MediaUploadResponse{
+		FileURL:    fmt.Sprintf("https://storage.cloud.google.com/%s/%s", bucketName, req.ObjectName),
+		FileName:   fileName,
+		FileType:   fileType,
+		UploadedAt: attrs.Created,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
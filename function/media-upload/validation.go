@@ -0,0 +1,231 @@
+package mediaupload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/yesaswi/synapscribe-backend/internal/jobs"
+)
+
+// sniffLen is how many leading bytes http.DetectContentType needs to see.
+const sniffLen = 512
+
+// allowedMIMETypes maps each fileType bucket to the real MIME types
+// http.DetectContentType is allowed to return for it. The filename
+// extension alone isn't trustworthy — a client can name arbitrary bytes
+// "track.mp3" and this cross-check catches that before the object ever
+// reaches AudioTranscription.
+//
+// application/octet-stream is deliberately absent here: it's the sniffer's
+// generic fallback for any byte sequence it doesn't recognize, so
+// whitelisting it outright would let arbitrary binaries through under an
+// audio/video extension. validateContentType instead falls back to
+// probeKnownContainer for the specific formats the sniffer can't
+// positively fingerprint (QuickTime .mov, MP3 without an ID3v2 tag).
+var allowedMIMETypes = map[string]map[string]bool{
+	"audio": {
+		"audio/mpeg":      true,
+		"audio/wave":      true,
+		"audio/ogg":       true,
+		"application/ogg": true,
+	},
+	"video": {
+		"video/mp4": true,
+		"video/avi": true,
+	},
+	"image": {
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/gif":  true,
+	},
+}
+
+// sniffHeader reads up to sniffLen bytes from r and returns them alongside
+// a reader that replays those bytes ahead of the rest of r, so the caller
+// can still read the whole file afterward.
+func sniffHeader(r io.Reader) ([]byte, io.Reader, error) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, fmt.Errorf("failed to read file header: %w", err)
+	}
+	buf = buf[:n]
+	return buf, io.MultiReader(bytes.NewReader(buf), r), nil
+}
+
+// validateContentType rejects a file whose sniffed MIME type doesn't
+// belong to fileType's whitelist, regardless of what its extension claims.
+// A generic application/octet-stream sniff is only accepted alongside a
+// passing probeKnownContainer check for ext, never on its own — otherwise
+// any unrecognizable byte sequence would bypass validation entirely.
+func validateContentType(fileType, ext string, header []byte) error {
+	contentType := http.DetectContentType(header)
+	if allowed, ok := allowedMIMETypes[fileType]; ok && allowed[contentType] {
+		return nil
+	}
+	if contentType == "application/octet-stream" && probeKnownContainer(ext, header) {
+		return nil
+	}
+	return fmt.Errorf("file content (%s) does not match expected type %q", contentType, fileType)
+}
+
+// probeKnownContainer recognizes formats http.DetectContentType has no
+// signature for by checking their container magic bytes directly, so
+// octet-stream isn't accepted as a blanket bypass for every fileType.
+func probeKnownContainer(ext string, header []byte) bool {
+	switch ext {
+	case ".mp3":
+		return isMP3(header)
+	case ".mov":
+		return isQuickTimeAtom(header)
+	default:
+		return false
+	}
+}
+
+// isMP3 recognizes an ID3v2 tag or a bare MPEG audio frame sync, since
+// ID3-less MP3s sniff as application/octet-stream.
+func isMP3(header []byte) bool {
+	if len(header) >= 3 && string(header[:3]) == "ID3" {
+		return true
+	}
+	return len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0
+}
+
+// isQuickTimeAtom recognizes a leading QuickTime/ISO-BMFF atom ("ftyp",
+// "moov", "free", "mdat", "wide"), since .mov has no signature Go's
+// sniffer checks for and falls back to application/octet-stream.
+func isQuickTimeAtom(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	switch string(header[4:8]) {
+	case "ftyp", "moov", "free", "mdat", "wide":
+		return true
+	default:
+		return false
+	}
+}
+
+// Scanner checks a stream for malware before it's treated as a finished
+// upload.
+type Scanner interface {
+	// Scan reports whether r is clean.
+	Scan(ctx context.Context, r io.Reader) (clean bool, err error)
+}
+
+// NewScanner returns a ClamAVScanner pointed at MEDIA_SCANNER_ADDR, or a
+// no-op scanner if it's unset so local dev doesn't need a clamd instance
+// running.
+func NewScanner() Scanner {
+	addr := os.Getenv("MEDIA_SCANNER_ADDR")
+	if addr == "" {
+		return noopScanner{}
+	}
+	return &ClamAVScanner{Addr: addr, Timeout: 30 * time.Second}
+}
+
+type noopScanner struct{}
+
+func (noopScanner) Scan(ctx context.Context, r io.Reader) (bool, error) {
+	return true, nil
+}
+
+// ClamAVScanner streams a file to a clamd instance over its INSTREAM
+// protocol and reports whether it came back clean.
+type ClamAVScanner struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (bool, error) {
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to scanner: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return false, fmt.Errorf("failed to start scan: %w", err)
+	}
+
+	chunk := make([]byte, 8192)
+	for {
+		n, readErr := r.Read(chunk)
+		if n > 0 {
+			size := make([]byte, 4)
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return false, fmt.Errorf("failed to stream to scanner: %w", err)
+			}
+			if _, err := conn.Write(chunk[:n]); err != nil {
+				return false, fmt.Errorf("failed to stream to scanner: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return false, fmt.Errorf("failed to read upload for scanning: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return false, fmt.Errorf("failed to finish scan stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read scan result: %w", err)
+	}
+
+	return strings.Contains(reply, "OK") && !strings.Contains(reply, "FOUND"), nil
+}
+
+// scanClean runs r through the configured Scanner and returns an error if
+// it isn't clean, or if the scan itself failed.
+func scanClean(ctx context.Context, r io.Reader) error {
+	clean, err := NewScanner().Scan(ctx, r)
+	if err != nil {
+		return fmt.Errorf("failed to scan uploaded file: %w", err)
+	}
+	if !clean {
+		return fmt.Errorf("uploaded file failed malware scan")
+	}
+	return nil
+}
+
+// scanObject streams an already-uploaded object through the configured
+// Scanner and, on a positive detection or a failed scan, deletes the
+// object and fails the job so a caller never sees a download link for
+// infected or unscanned content.
+//
+// This is necessarily post-hoc: it's used by the signed-URL finalize path,
+// where the client PUTs straight to GCS and the function never sees the
+// bytes in flight, so there's no way to scan before the object exists. The
+// server-mediated upload path scans the buffered content before it's ever
+// written to GCS instead — see MediaUpload.
+func scanObject(ctx context.Context, obj *storage.ObjectHandle, objectName string) error {
+	reader, err := obj.NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read uploaded object for scanning: %w", err)
+	}
+	defer reader.Close()
+
+	if err := scanClean(ctx, reader); err != nil {
+		obj.Delete(ctx)
+		jobs.SetFailed(ctx, objectName, err)
+		return err
+	}
+	return nil
+}
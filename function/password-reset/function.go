@@ -0,0 +1,47 @@
+package passwordreset
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/firebaseauth"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+)
+
+func init() {
+	functions.HTTP("PasswordReset", httpx.WithCORS(httpx.WithJSONErrors(PasswordReset)))
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func PasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" {
+		httpx.WriteError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	authClient, err := firebaseauth.NewClient()
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to initialize auth client")
+		return
+	}
+
+	// Always respond 200 regardless of outcome — surfacing whether the
+	// account exists (e.g. EMAIL_NOT_FOUND) would turn this unauthenticated
+	// endpoint into an account-enumeration oracle. Log the real error
+	// server-side instead.
+	if err := authClient.SendPasswordResetEmail(r.Context(), req.Email); err != nil {
+		httpx.LogJSON("Password reset request failed: "+err.Error(), httpx.SeverityError)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
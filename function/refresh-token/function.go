@@ -0,0 +1,60 @@
+package refreshtoken
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/firebaseauth"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+)
+
+func init() {
+	functions.HTTP("RefreshToken", httpx.WithCORS(httpx.WithJSONErrors(RefreshToken)))
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshTokenResponse struct {
+	IDToken      string `json:"idToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    string `json:"expiresIn"`
+}
+
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		httpx.WriteError(w, http.StatusBadRequest, "refreshToken is required")
+		return
+	}
+
+	authClient, err := firebaseauth.NewClient()
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to initialize auth client")
+		return
+	}
+
+	resp, err := authClient.RefreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		if apiErr, ok := err.(*firebaseauth.APIError); ok {
+			httpx.WriteError(w, apiErr.HTTPStatus, "Token refresh failed: "+apiErr.Code)
+			return
+		}
+		httpx.WriteError(w, http.StatusUnauthorized, "Token refresh failed: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RefreshTokenResponse{
+		IDToken:      resp.IDToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    resp.ExpiresIn,
+	})
+}
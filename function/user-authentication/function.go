@@ -1,20 +1,16 @@
 package userauthentication
 
 import (
-	"bytes"
-	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
-	"os"
 
-	firebase "firebase.google.com/go/v4"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/firebaseauth"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
 )
 
 func init() {
-	functions.HTTP("UserAuthentication", UserAuthentication)
+	functions.HTTP("UserAuthentication", httpx.WithCORS(httpx.WithJSONErrors(UserAuthentication)))
 }
 
 type LoginRequest struct {
@@ -23,8 +19,9 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	IDToken string `json:"idToken"`
-	User    User   `json:"user"`
+	IDToken      string `json:"idToken"`
+	RefreshToken string `json:"refreshToken"`
+	User         User   `json:"user"`
 }
 
 type User struct {
@@ -33,95 +30,55 @@ type User struct {
 	Name  string `json:"name"`
 }
 
-type FirebaseSignInResponse struct {
-	IDToken      string `json:"idToken"`
-	Email        string `json:"email"`
-	RefreshToken string `json:"refreshToken"`
-	ExpiresIn    string `json:"expiresIn"`
-	LocalID      string `json:"localId"`
-	DisplayName  string `json:"displayName"`
-}
-
 func UserAuthentication(w http.ResponseWriter, r *http.Request) {
 	var loginReq LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// Step 1: Authenticate with Firebase
-	firebaseResp, err := authenticateWithFirebase(loginReq.Email, loginReq.Password)
+	authClient, err := firebaseauth.NewClient()
 	if err != nil {
-		http.Error(w, "Authentication failed: "+err.Error(), http.StatusUnauthorized)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to initialize auth client")
 		return
 	}
 
-	// Step 2: Verify the ID token
-	ctx := context.Background()
-	app, err := firebase.NewApp(ctx, nil)
+	ctx := r.Context()
+
+	// Step 1: Authenticate with Firebase
+	signInResp, err := authClient.SignInWithPassword(ctx, loginReq.Email, loginReq.Password)
 	if err != nil {
-		http.Error(w, "Failed to initialize Firebase app", http.StatusInternalServerError)
+		if apiErr, ok := err.(*firebaseauth.APIError); ok {
+			httpx.WriteError(w, apiErr.HTTPStatus, "Authentication failed: "+apiErr.Code)
+			return
+		}
+		httpx.WriteError(w, http.StatusUnauthorized, "Authentication failed: "+err.Error())
 		return
 	}
 
-	client, err := app.Auth(ctx)
+	// Step 2: Verify the ID token
+	verifyClient, err := httpx.AuthClient(ctx)
 	if err != nil {
-		http.Error(w, "Failed to get Firebase Auth client", http.StatusInternalServerError)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to get Firebase Auth client")
 		return
 	}
 
-	token, err := client.VerifyIDToken(ctx, firebaseResp.IDToken)
+	token, err := verifyClient.VerifyIDToken(ctx, signInResp.IDToken)
 	if err != nil {
-		http.Error(w, "Invalid ID token", http.StatusUnauthorized)
+		httpx.WriteError(w, http.StatusUnauthorized, "Invalid ID token")
 		return
 	}
 
 	response := LoginResponse{
-		IDToken: firebaseResp.IDToken,
+		IDToken:      signInResp.IDToken,
+		RefreshToken: signInResp.RefreshToken,
 		User: User{
 			ID:    token.UID,
-			Email: firebaseResp.Email,
-			Name:  firebaseResp.DisplayName,
+			Email: signInResp.Email,
+			Name:  signInResp.DisplayName,
 		},
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
-
-func authenticateWithFirebase(email, password string) (*FirebaseSignInResponse, error) {
-	apiKey := os.Getenv("FIREBASE_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("FIREBASE_API_KEY environment variable is not set")
-	}
-
-	url := fmt.Sprintf("https://identitytoolkit.googleapis.com/v1/accounts:signInWithPassword?key=%s", apiKey)
-
-	requestBody, _ := json.Marshal(map[string]interface{}{
-		"email":             email,
-		"password":          password,
-		"returnSecureToken": true,
-	})
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("authentication failed: %s", body)
-	}
-
-	var firebaseResp FirebaseSignInResponse
-	if err := json.Unmarshal(body, &firebaseResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	return &firebaseResp, nil
-}
@@ -1,20 +1,17 @@
 package userprofilemanagement
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
-	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
 )
 
 func init() {
-	functions.HTTP("UserProfileManagement", UserProfileManagement)
+	functions.HTTP("UserProfileManagement", httpx.WithCORS(httpx.WithAuth(httpx.WithJSONErrors(UserProfileManagement))))
 }
 
 type UserProfile struct {
@@ -29,52 +26,33 @@ type UserProfileUpdate struct {
 }
 
 func UserProfileManagement(w http.ResponseWriter, r *http.Request) {
-	// Initialize Firebase app
-	ctx := context.Background()
-	config := &firebase.Config{
-		ProjectID: "synapscribe",
-	}
-	app, err := firebase.NewApp(ctx, config)
-	if err != nil {
-		http.Error(w, "Error initializing app: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Get Firebase Auth client
-	authClient, err := app.Auth(ctx)
-	if err != nil {
-		http.Error(w, "Error getting Auth client: "+err.Error(), http.StatusInternalServerError)
+	ctx := r.Context()
+	uid, ok := httpx.UIDFromContext(ctx)
+	if !ok {
+		httpx.WriteError(w, http.StatusUnauthorized, "No token provided")
 		return
 	}
 
-	// Verify Firebase ID token
-	idToken := extractToken(r)
-	fmt.Println("Received token:", idToken)
-	if idToken == "" {
-		http.Error(w, "No token provided", http.StatusUnauthorized)
-		return
-	}
-
-	token, err := authClient.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+	authClient, err := httpx.AuthClient(ctx)
 	if err != nil {
-		http.Error(w, "Invalid token: " + err.Error(), http.StatusUnauthorized)
+		httpx.WriteError(w, http.StatusInternalServerError, "Error getting Auth client: "+err.Error())
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		handleGetProfile(w, r, authClient, token.UID)
+		handleGetProfile(w, r, authClient, uid)
 	case http.MethodPut:
-		handleUpdateProfile(w, r, authClient, token.UID)
+		handleUpdateProfile(w, r, authClient, uid)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpx.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
 func handleGetProfile(w http.ResponseWriter, r *http.Request, authClient *auth.Client, uid string) {
 	user, err := authClient.GetUser(r.Context(), uid)
 	if err != nil {
-		http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
+		httpx.WriteError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
 		return
 	}
 
@@ -92,14 +70,14 @@ func handleGetProfile(w http.ResponseWriter, r *http.Request, authClient *auth.C
 func handleUpdateProfile(w http.ResponseWriter, r *http.Request, authClient *auth.Client, uid string) {
 	var update UserProfileUpdate
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return
 	}
 
 	params := (&auth.UserToUpdate{}).DisplayName(update.Name)
 	user, err := authClient.UpdateUser(r.Context(), uid, params)
 	if err != nil {
-		http.Error(w, "Error updating user: "+err.Error(), http.StatusInternalServerError)
+		httpx.WriteError(w, http.StatusInternalServerError, "Error updating user: "+err.Error())
 		return
 	}
 
@@ -113,12 +91,3 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request, authClient *aut
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedProfile)
 }
-
-func extractToken(r *http.Request) string {
-	// Extract token from Authorization header
-	bearerToken := r.Header.Get("X-Forwarded-Authorization")
-	if bearerToken != "" && strings.HasPrefix(bearerToken, "Bearer ") {
-		return strings.TrimPrefix(bearerToken, "Bearer ")
-	}
-	return ""
-}
@@ -8,13 +8,13 @@ import (
 	"regexp"
 	"strings"
 
-	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
 )
 
 func init() {
-	functions.HTTP("UserRegistration", UserRegistration)
+	functions.HTTP("UserRegistration", httpx.WithCORS(httpx.WithJSONErrors(UserRegistration)))
 }
 
 type User struct {
@@ -72,12 +72,7 @@ func containsNumber(s string) bool {
 }
 
 func RegisterUser(ctx context.Context, user *User) (string, error) {
-	app, err := firebase.NewApp(ctx, nil)
-	if err != nil {
-		return "", err
-	}
-
-	client, err := app.Auth(ctx)
+	client, err := httpx.AuthClient(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -98,18 +93,18 @@ func RegisterUser(ctx context.Context, user *User) (string, error) {
 func UserRegistration(w http.ResponseWriter, r *http.Request) {
 	var user User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpx.WriteError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := user.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	userID, err := RegisterUser(r.Context(), &user)
 	if err != nil {
-		http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to register user")
 		return
 	}
 
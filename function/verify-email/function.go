@@ -0,0 +1,38 @@
+package verifyemail
+
+import (
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/yesaswi/synapscribe-backend/internal/firebaseauth"
+	"github.com/yesaswi/synapscribe-backend/internal/httpx"
+)
+
+func init() {
+	functions.HTTP("VerifyEmail", httpx.WithCORS(httpx.WithJSONErrors(VerifyEmail)))
+}
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	idToken := httpx.ExtractToken(r)
+	if idToken == "" {
+		httpx.WriteError(w, http.StatusUnauthorized, "No token provided")
+		return
+	}
+
+	authClient, err := firebaseauth.NewClient()
+	if err != nil {
+		httpx.WriteError(w, http.StatusInternalServerError, "Failed to initialize auth client")
+		return
+	}
+
+	if err := authClient.SendEmailVerification(r.Context(), idToken); err != nil {
+		if apiErr, ok := err.(*firebaseauth.APIError); ok {
+			httpx.WriteError(w, apiErr.HTTPStatus, "Sending verification email failed: "+apiErr.Code)
+			return
+		}
+		httpx.WriteError(w, http.StatusBadRequest, "Sending verification email failed: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,96 @@
+// Package events defines the Pub/Sub message contracts shared between
+// producers (mediaupload) and consumers (audiotranscription, and future
+// subscribers like video thumbnailing or image EXIF extraction) so they
+// stay in sync without a direct function-to-function dependency.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// MediaUploadedTopic is the Pub/Sub topic MediaUpload publishes to once a
+// file has finished uploading and passed validation.
+const MediaUploadedTopic = "media.uploaded"
+
+// MediaUploaded is published after a file finishes uploading to GCS.
+// Subscribers filter on FileType rather than each triggering off every
+// object written to the bucket.
+type MediaUploaded struct {
+	UserID   string `json:"userID"`
+	Bucket   string `json:"bucket"`
+	Object   string `json:"object"`
+	FileType string `json:"fileType"`
+	JobID    string `json:"jobID"`
+}
+
+var (
+	clientMu sync.Mutex
+	client   *pubsub.Client
+)
+
+// getClient lazily creates the process-wide Pub/Sub client so repeated
+// invocations on a warm Cloud Function instance reuse the same connection.
+// A failed initialization isn't cached, so a transient cold-start error
+// doesn't wedge every request on this instance for the rest of its
+// lifetime.
+func getClient(ctx context.Context) (*pubsub.Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+	c, err := pubsub.NewClient(ctx, pubsub.DetectProjectID)
+	if err != nil {
+		return nil, err
+	}
+	client = c
+	return client, nil
+}
+
+var (
+	mediaUploadedTopicMu sync.Mutex
+	mediaUploadedTopic   *pubsub.Topic
+)
+
+// getMediaUploadedTopic lazily creates the process-wide Topic handle.
+// pubsub.Topic owns a publish-scheduler goroutine, so it must be reused
+// across calls rather than created fresh per publish and left to leak.
+// Like getClient, a failed initialization isn't cached.
+func getMediaUploadedTopic(ctx context.Context) (*pubsub.Topic, error) {
+	mediaUploadedTopicMu.Lock()
+	defer mediaUploadedTopicMu.Unlock()
+	if mediaUploadedTopic != nil {
+		return mediaUploadedTopic, nil
+	}
+	psClient, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mediaUploadedTopic = psClient.Topic(MediaUploadedTopic)
+	return mediaUploadedTopic, nil
+}
+
+// PublishMediaUploaded publishes a MediaUploaded event to MediaUploadedTopic
+// and waits for the publish to complete.
+func PublishMediaUploaded(ctx context.Context, evt MediaUploaded) error {
+	topic, err := getMediaUploadedTopic(ctx)
+	if err != nil {
+		return fmt.Errorf("events: failed to get pubsub topic: %w", err)
+	}
+
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("events: failed to encode event: %w", err)
+	}
+
+	result := topic.Publish(ctx, &pubsub.Message{Data: data})
+	if _, err := result.Get(ctx); err != nil {
+		return fmt.Errorf("events: failed to publish event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+// Package firebaseauth wraps the Identity Toolkit v1 REST API so the
+// project's Cloud Functions can sign in, refresh tokens, and manage
+// passwords without pulling the full Firebase Admin SDK into every cold
+// start.
+package firebaseauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	identityToolkitBaseURL = "https://identitytoolkit.googleapis.com/v1"
+	secureTokenBaseURL     = "https://securetoken.googleapis.com/v1"
+
+	requestTimeout = 10 * time.Second
+	maxRetries     = 2
+	retryBackoff   = 250 * time.Millisecond
+)
+
+// Client talks to the Identity Toolkit v1 REST API with a shared,
+// timeout-bound http.Client.
+type Client struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewClient builds a Client using FIREBASE_API_KEY from the environment.
+func NewClient() (*Client, error) {
+	apiKey := os.Getenv("FIREBASE_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("FIREBASE_API_KEY environment variable is not set")
+	}
+
+	return &Client{
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// APIError is a structured Identity Toolkit error, mapped to the HTTP
+// status a caller should actually return.
+type APIError struct {
+	HTTPStatus int
+	Code       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("firebaseauth: %s", e.Code)
+}
+
+// httpStatusForCode maps Identity Toolkit's string error codes to the
+// HTTP status callers should surface to their own clients.
+func httpStatusForCode(code string) int {
+	switch {
+	case strings.HasPrefix(code, "WEAK_PASSWORD"):
+		return http.StatusBadRequest
+	case code == "EMAIL_EXISTS":
+		return http.StatusConflict
+	case code == "EMAIL_NOT_FOUND",
+		code == "INVALID_PASSWORD",
+		code == "INVALID_LOGIN_CREDENTIALS",
+		code == "USER_DISABLED",
+		code == "USER_NOT_FOUND",
+		code == "TOKEN_EXPIRED",
+		code == "INVALID_ID_TOKEN",
+		code == "INVALID_REFRESH_TOKEN":
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+type errorEnvelope struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func mapAPIError(httpStatus int, body []byte) error {
+	var envelope errorEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error.Message == "" {
+		return &APIError{HTTPStatus: httpStatus, Code: "UNKNOWN_ERROR"}
+	}
+	code := envelope.Error.Message
+	return &APIError{HTTPStatus: httpStatusForCode(code), Code: code}
+}
+
+// do POSTs a JSON request to an Identity Toolkit endpoint, retrying on
+// transient network and server errors.
+func (c *Client) do(ctx context.Context, endpoint string, reqBody, respBody interface{}) error {
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("firebaseauth: failed to encode request: %w", err)
+	}
+
+	reqURL := fmt.Sprintf("%s/%s?key=%s", identityToolkitBaseURL, endpoint, c.apiKey)
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * retryBackoff)
+		}
+
+		status, body, err := c.post(ctx, reqURL, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if status >= http.StatusInternalServerError {
+			lastErr = fmt.Errorf("firebaseauth: server error %d: %s", status, body)
+			continue
+		}
+
+		if status != http.StatusOK {
+			return mapAPIError(status, body)
+		}
+
+		if respBody != nil {
+			if err := json.Unmarshal(body, respBody); err != nil {
+				return fmt.Errorf("firebaseauth: failed to decode response: %w", err)
+			}
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+func (c *Client) post(ctx context.Context, reqURL, contentType string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("firebaseauth: failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("firebaseauth: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("firebaseauth: failed to read response: %w", err)
+	}
+
+	return resp.StatusCode, respBody, nil
+}
+
+// SignInResponse is the response to accounts:signInWithPassword.
+type SignInResponse struct {
+	IDToken      string `json:"idToken"`
+	Email        string `json:"email"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    string `json:"expiresIn"`
+	LocalID      string `json:"localId"`
+	DisplayName  string `json:"displayName"`
+}
+
+// SignInWithPassword authenticates a user with email and password.
+func (c *Client) SignInWithPassword(ctx context.Context, email, password string) (*SignInResponse, error) {
+	var resp SignInResponse
+	if err := c.do(ctx, "accounts:signInWithPassword", map[string]interface{}{
+		"email":             email,
+		"password":          password,
+		"returnSecureToken": true,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RefreshTokenResponse is the response to the Secure Token API's token
+// refresh grant.
+type RefreshTokenResponse struct {
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    string `json:"expires_in"`
+	UserID       string `json:"user_id"`
+}
+
+// RefreshToken exchanges a refresh token for a new ID token. This hits
+// securetoken.googleapis.com rather than identitytoolkit.googleapis.com
+// and is form-encoded rather than JSON, so it doesn't go through do().
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*RefreshTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+	reqURL := fmt.Sprintf("%s/token?key=%s", secureTokenBaseURL, c.apiKey)
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * retryBackoff)
+		}
+
+		status, body, err := c.post(ctx, reqURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if status >= http.StatusInternalServerError {
+			lastErr = fmt.Errorf("firebaseauth: server error %d: %s", status, body)
+			continue
+		}
+
+		if status != http.StatusOK {
+			return nil, mapAPIError(status, body)
+		}
+
+		var resp RefreshTokenResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("firebaseauth: failed to decode response: %w", err)
+		}
+		return &resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// SendPasswordResetEmail requests a password reset OOB code for email.
+func (c *Client) SendPasswordResetEmail(ctx context.Context, email string) error {
+	return c.do(ctx, "accounts:sendOobCode", map[string]interface{}{
+		"requestType": "PASSWORD_RESET",
+		"email":       email,
+	}, nil)
+}
+
+// SendEmailVerification requests a verification OOB code for the account
+// behind idToken.
+func (c *Client) SendEmailVerification(ctx context.Context, idToken string) error {
+	return c.do(ctx, "accounts:sendOobCode", map[string]interface{}{
+		"requestType": "VERIFY_EMAIL",
+		"idToken":     idToken,
+	}, nil)
+}
+
+// UpdatePasswordResponse is the response to accounts:update when changing
+// a password.
+type UpdatePasswordResponse struct {
+	LocalID string `json:"localId"`
+	Email   string `json:"email"`
+	IDToken string `json:"idToken"`
+}
+
+// UpdatePassword changes the password for the account behind idToken.
+func (c *Client) UpdatePassword(ctx context.Context, idToken, newPassword string) (*UpdatePasswordResponse, error) {
+	var resp UpdatePasswordResponse
+	if err := c.do(ctx, "accounts:update", map[string]interface{}{
+		"idToken":           idToken,
+		"password":          newPassword,
+		"returnSecureToken": true,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,183 @@
+// Package httpx provides the middleware every HTTP Cloud Function in this
+// repo composes: a shared Firebase app/Auth client, token verification,
+// CORS, and a structured JSON error shape. It removes the need for each
+// function to re-initialize firebase.NewApp on every request and to
+// hand-roll its own error format.
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/auth"
+)
+
+var (
+	appMu sync.Mutex
+	app   *firebase.App
+)
+
+// FirebaseApp returns a process-wide Firebase app, initialized once per
+// warm Cloud Function instance instead of on every request. A failed
+// initialization isn't cached, so a transient cold-start error doesn't
+// wedge every request on this instance for the rest of its lifetime.
+func FirebaseApp(ctx context.Context) (*firebase.App, error) {
+	appMu.Lock()
+	defer appMu.Unlock()
+	if app != nil {
+		return app, nil
+	}
+	a, err := firebase.NewApp(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	app = a
+	return app, nil
+}
+
+var (
+	authMu     sync.Mutex
+	authClient *auth.Client
+)
+
+// AuthClient returns a process-wide Firebase Auth client, built on top of
+// the shared FirebaseApp. Like FirebaseApp, a failed initialization isn't
+// cached.
+func AuthClient(ctx context.Context) (*auth.Client, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if authClient != nil {
+		return authClient, nil
+	}
+	a, err := FirebaseApp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := a.Auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	authClient = c
+	return authClient, nil
+}
+
+type contextKey string
+
+const uidContextKey contextKey = "httpx-uid"
+
+// UIDFromContext returns the authenticated caller's uid, as set by
+// WithAuth.
+func UIDFromContext(ctx context.Context) (string, bool) {
+	uid, ok := ctx.Value(uidContextKey).(string)
+	return uid, ok
+}
+
+// ExtractToken reads the bearer ID token the Functions Framework proxy
+// forwards in X-Forwarded-Authorization. It's exported for the handful of
+// handlers (e.g. VerifyEmail) that need the raw token itself rather than
+// just the verified uid.
+func ExtractToken(r *http.Request) string {
+	bearerToken := r.Header.Get("X-Forwarded-Authorization")
+	if bearerToken != "" && strings.HasPrefix(bearerToken, "Bearer ") {
+		return strings.TrimPrefix(bearerToken, "Bearer ")
+	}
+	return ""
+}
+
+// WithAuth verifies the caller's Firebase ID token and stores the uid in
+// the request context for downstream handlers to read via
+// UIDFromContext.
+func WithAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authClient, err := AuthClient(ctx)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "Failed to initialize auth client")
+			return
+		}
+
+		idToken := ExtractToken(r)
+		if idToken == "" {
+			WriteError(w, http.StatusUnauthorized, "No token provided")
+			return
+		}
+
+		token, err := authClient.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+		if err != nil {
+			WriteError(w, http.StatusUnauthorized, "Invalid token: "+err.Error())
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(ctx, uidContextKey, token.UID)))
+	}
+}
+
+// WithCORS adds the CORS headers the browser frontend needs and
+// short-circuits preflight OPTIONS requests.
+func WithCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Forwarded-Authorization")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// ErrorResponse is the structured error body every function returns on
+// failure, promoted from mediaupload's original shape.
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteError writes a structured JSON error response.
+func WriteError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(ErrorResponse{Code: statusCode, Message: message})
+}
+
+// WithJSONErrors recovers a panic in next and turns it into a structured
+// JSON error response instead of a bare connection reset.
+func WithJSONErrors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				WriteError(w, http.StatusInternalServerError, fmt.Sprintf("internal error: %v", rec))
+			}
+		}()
+		next(w, r)
+	}
+}
+
+// Severity levels for LogJSON, matching what Cloud Logging expects.
+const (
+	SeverityInfo  = "INFO"
+	SeverityError = "ERROR"
+)
+
+// LogJSON emits a structured log line Cloud Logging can parse for
+// severity, promoted from the pattern audiotranscription used locally.
+func LogJSON(message string, severity string) {
+	logEntry := struct {
+		Message  string `json:"message"`
+		Severity string `json:"severity"`
+	}{
+		Message:  message,
+		Severity: severity,
+	}
+	jsonLog, _ := json.Marshal(logEntry)
+	fmt.Println(string(jsonLog))
+}
@@ -0,0 +1,195 @@
+// Package jobs tracks transcription job progress in Firestore so clients can
+// poll a long-running upload/transcription pipeline instead of relying on
+// the fire-and-forget CloudEvent flow.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Status is the lifecycle state of a job, advancing from Pending through to
+// either Done or Failed.
+type Status string
+
+const (
+	StatusPending      Status = "pending"
+	StatusUploading    Status = "uploading"
+	StatusTranscribing Status = "transcribing"
+	StatusDone         Status = "done"
+	StatusFailed       Status = "failed"
+)
+
+const collectionName = "jobs"
+
+// SegmentStatus is the lifecycle state of a single chunk of a long-audio
+// transcription.
+type SegmentStatus string
+
+const (
+	SegmentPending SegmentStatus = "pending"
+	SegmentDone    SegmentStatus = "done"
+	SegmentFailed  SegmentStatus = "failed"
+)
+
+// SegmentResult records the outcome of transcribing one segment of a
+// chunked job, so a failed segment can be retried without redoing the
+// whole file.
+type SegmentResult struct {
+	Index      int           `firestore:"index"`
+	Status     SegmentStatus `firestore:"status"`
+	Transcript string        `firestore:"transcript,omitempty"`
+	Error      string        `firestore:"error,omitempty"`
+}
+
+// Job is the Firestore-backed record of a single upload's progress.
+type Job struct {
+	ID            string                   `firestore:"-"`
+	UID           string                   `firestore:"uid"`
+	Bucket        string                   `firestore:"bucket"`
+	ObjectName    string                   `firestore:"objectName"`
+	Status        Status                   `firestore:"status"`
+	Error         string                   `firestore:"error,omitempty"`
+	TranscriptURL string                   `firestore:"transcriptUrl,omitempty"`
+	Segments      map[string]SegmentResult `firestore:"segments,omitempty"`
+	CreatedAt     time.Time                `firestore:"createdAt"`
+	UpdatedAt     time.Time                `firestore:"updatedAt"`
+}
+
+var (
+	clientMu sync.Mutex
+	client   *firestore.Client
+)
+
+// getClient lazily creates the process-wide Firestore client so repeated
+// invocations on a warm Cloud Function instance reuse the same connection.
+// A failed initialization isn't cached, so a transient cold-start error
+// doesn't wedge every request on this instance for the rest of its
+// lifetime.
+func getClient(ctx context.Context) (*firestore.Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+	c, err := firestore.NewClient(ctx, firestore.DetectProjectID)
+	if err != nil {
+		return nil, err
+	}
+	client = c
+	return client, nil
+}
+
+// Create records a new job for the given uploaded object, owned by uid.
+func Create(ctx context.Context, uid, bucket, objectName string, status Status) error {
+	fsClient, err := getClient(ctx)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to get firestore client: %w", err)
+	}
+
+	now := time.Now()
+	_, err = fsClient.Collection(collectionName).Doc(DocID(objectName)).Set(ctx, Job{
+		UID:        uid,
+		Bucket:     bucket,
+		ObjectName: objectName,
+		Status:     status,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return fmt.Errorf("jobs: failed to create job for %s: %w", objectName, err)
+	}
+	return nil
+}
+
+// SetStatus advances a job to the given status.
+func SetStatus(ctx context.Context, objectName string, status Status) error {
+	return update(ctx, objectName, map[string]interface{}{
+		"status":    status,
+		"updatedAt": time.Now(),
+	})
+}
+
+// SetFailed marks a job failed and records the cause for the client to
+// surface.
+func SetFailed(ctx context.Context, objectName string, cause error) error {
+	return update(ctx, objectName, map[string]interface{}{
+		"status":    StatusFailed,
+		"error":     cause.Error(),
+		"updatedAt": time.Now(),
+	})
+}
+
+// SetDone marks a job complete with the signed URL of its finished
+// transcript.
+func SetDone(ctx context.Context, objectName, transcriptURL string) error {
+	return update(ctx, objectName, map[string]interface{}{
+		"status":        StatusDone,
+		"transcriptUrl": transcriptURL,
+		"updatedAt":     time.Now(),
+	})
+}
+
+// SetSegmentResult records the outcome of a single segment so long-audio
+// jobs can be retried segment-by-segment instead of from scratch.
+func SetSegmentResult(ctx context.Context, objectName string, result SegmentResult) error {
+	return update(ctx, objectName, map[string]interface{}{
+		fmt.Sprintf("segments.%d", result.Index): result,
+		"updatedAt":                              time.Now(),
+	})
+}
+
+func update(ctx context.Context, objectName string, fields map[string]interface{}) error {
+	fsClient, err := getClient(ctx)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to get firestore client: %w", err)
+	}
+
+	updates := make([]firestore.Update, 0, len(fields))
+	for path, value := range fields {
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+
+	if _, err := fsClient.Collection(collectionName).Doc(DocID(objectName)).Update(ctx, updates); err != nil {
+		return fmt.Errorf("jobs: failed to update job %s: %w", objectName, err)
+	}
+	return nil
+}
+
+// Get fetches a job by its object name, scoped to uid so a caller can only
+// ever read back their own jobs.
+func Get(ctx context.Context, uid, objectName string) (*Job, error) {
+	fsClient, err := getClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to get firestore client: %w", err)
+	}
+
+	snap, err := fsClient.Collection(collectionName).Doc(DocID(objectName)).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to get job %s: %w", objectName, err)
+	}
+
+	var job Job
+	if err := snap.DataTo(&job); err != nil {
+		return nil, fmt.Errorf("jobs: failed to decode job %s: %w", objectName, err)
+	}
+	job.ID = snap.Ref.ID
+
+	if job.UID != uid {
+		return nil, fmt.Errorf("jobs: job %s does not belong to caller", objectName)
+	}
+
+	return &job, nil
+}
+
+// DocID turns an object name into a Firestore-safe document ID; object
+// names contain "/", which Firestore document IDs cannot. It also doubles
+// as the jobID handed to subscribers over Pub/Sub.
+func DocID(objectName string) string {
+	return strings.ReplaceAll(objectName, "/", "_")
+}